@@ -0,0 +1,76 @@
+// Copyright (c) 2021-2022 Nordix Foundation.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package l2resourcecfg reads the YAML file that maps NSM labels to OvS bridges
+// and interfaces, used to resolve an egress L2 connection point for a Request.
+package l2resourcecfg
+
+import (
+	"context"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/networkservicemesh/sdk/pkg/tools/log"
+)
+
+// LabelSelector matches a Request label to a via value used as the key in the
+// resulting egress connection point map.
+type LabelSelector struct {
+	Via string `yaml:"via"`
+}
+
+// Match lists the label selectors that resolve to a given interface or bridge.
+type Match struct {
+	LabelSelector []LabelSelector `yaml:"labelSelector"`
+}
+
+// Interface describes a host netdev egress point and the bridge it is attached to.
+type Interface struct {
+	Name    string  `yaml:"name"`
+	Bridge  string  `yaml:"bridge"`
+	Matches []Match `yaml:"matches"`
+}
+
+// Bridge describes an OvS bridge egress point.
+type Bridge struct {
+	Name    string  `yaml:"name"`
+	Matches []Match `yaml:"matches"`
+}
+
+// Config is the parsed content of the L2ResourceSelectorFile.
+type Config struct {
+	Interfaces []Interface `yaml:"interfaces"`
+	Bridges    []Bridge    `yaml:"bridges"`
+}
+
+// ReadConfig reads and parses the resource-to-label-selector YAML file at path.
+func ReadConfig(ctx context.Context, path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read l2 resource selector file %q", path)
+	}
+
+	cfg := &Config{}
+	if err = yaml.Unmarshal(data, cfg); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse l2 resource selector file %q", path)
+	}
+
+	log.FromContext(ctx).WithField("path", path).Debug("parsed l2 resource selector file")
+
+	return cfg, nil
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2022 Nordix Foundation.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package l2resourcecfg
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+
+	ovsutil "github.com/networkservicemesh/sdk-ovs/pkg/tools/utils"
+)
+
+// Store holds an atomically-swappable via -> L2ConnectionPoint map, so that the
+// L2ResourceSelectorFile can be reloaded without restarting the forwarder.
+// Existing connections keep whatever egress point they already resolved; only
+// new Requests observe a reload.
+//
+// Callers that hold on to a resolved egress point past the call to Get - i.e.
+// for the lifetime of the connection it was resolved for - must call Release
+// with the same via once that connection closes, so Reload's in-use tracking
+// reflects connections that are actually still open rather than every via
+// that was ever requested. Get and Release are handed to the forwarder server
+// as a pair so it can call Release from its connection-close path.
+type Store struct {
+	mu     sync.RWMutex
+	points map[string]*ovsutil.L2ConnectionPoint
+	refs   map[string]int
+}
+
+// NewStore wraps an initial via -> L2ConnectionPoint map, which may be nil.
+func NewStore(points map[string]*ovsutil.L2ConnectionPoint) *Store {
+	return &Store{
+		points: points,
+		refs:   make(map[string]int),
+	}
+}
+
+// Get resolves via to its current egress point and increments its reference
+// count, so a future Reload won't silently drop it out from under a live
+// connection. Each call to Get must be paired with a later call to Release
+// once the connection it was resolved for closes.
+func (s *Store) Get(via string) *ovsutil.L2ConnectionPoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refs[via]++
+	return s.points[via]
+}
+
+// Release drops a reference to via previously acquired through Get. It must be
+// called exactly once for every prior Get(via) when the corresponding
+// connection closes.
+func (s *Store) Release(via string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refs[via] <= 1 {
+		delete(s.refs, via)
+		return
+	}
+	s.refs[via]--
+}
+
+// Snapshot returns the current via -> L2ConnectionPoint map.
+func (s *Store) Snapshot() map[string]*ovsutil.L2ConnectionPoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.points
+}
+
+// Reload atomically swaps in points, unless doing so would remove the egress of
+// a via that has a connection open against it right now, in which case the
+// existing map is kept and an error is returned describing the conflicting
+// via values.
+func (s *Store) Reload(points map[string]*ovsutil.L2ConnectionPoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removedInUse []string
+	for via, count := range s.refs {
+		if count <= 0 {
+			continue
+		}
+		if _, present := s.points[via]; !present {
+			// via has no egress in the current map, so the reload cannot remove one.
+			continue
+		}
+		if _, stillPresent := points[via]; !stillPresent {
+			removedInUse = append(removedInUse, via)
+		}
+	}
+	if len(removedInUse) > 0 {
+		return errors.Errorf("reload rejected: egress(es) %v are in use by existing connections and missing from the new config", removedInUse)
+	}
+
+	s.points = points
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2021-2022 Nordix Foundation.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ovsinit manages the lifecycle of the Open vSwitch daemons (ovsdb-server,
+// ovs-vswitchd) that the forwarder depends on.
+package ovsinit
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/pkg/errors"
+)
+
+const defaultOvsdbSock = "unix:/var/run/openvswitch/db.sock"
+
+// IsOvsRunning reports whether ovsdb-server is already reachable, which means a
+// host-level OvS is being used and the forwarder shouldn't start its own.
+func IsOvsRunning() bool {
+	return exec.Command("ovs-vsctl", "show").Run() == nil
+}
+
+// StartSupervisord starts supervisord, which in turn starts ovsdb-server and
+// ovs-vswitchd, each with its own starting timeout and retries. The returned
+// channel receives an error if supervisord exits.
+func StartSupervisord(ctx context.Context) <-chan error {
+	errCh := make(chan error, 1)
+	cmd := exec.CommandContext(ctx, "supervisord", "-c", "/etc/supervisord.conf")
+	if err := cmd.Start(); err != nil {
+		errCh <- errors.Wrap(err, "failed to start supervisord")
+		return errCh
+	}
+	go func() {
+		errCh <- cmd.Wait()
+	}()
+	return errCh
+}
+
+// CheckReady makes a single attempt to confirm the local ovsdb-server is responding.
+// Callers that need to wait for readiness should retry this themselves, e.g. with a
+// startup retry loop, rather than relying on an internal poll here.
+func CheckReady(ctx context.Context) error {
+	if out, err := exec.CommandContext(ctx, "ovs-vsctl", "show").CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "ovs is not ready: %s", string(out))
+	}
+	return nil
+}
+
+// CheckEndpointReady makes a single attempt to confirm that the OVSDB server at endpoint
+// (e.g. "unix:/host/var/run/openvswitch/db.sock" or "tcp:10.0.0.5:6640") is reachable.
+// Callers that need to wait for readiness should retry this themselves.
+func CheckEndpointReady(ctx context.Context, endpoint string) error {
+	if endpoint == "" {
+		endpoint = defaultOvsdbSock
+	}
+	out, err := exec.CommandContext(ctx, "ovs-vsctl", "--db="+endpoint, "show").CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "ovsdb endpoint %s not reachable: %s", endpoint, string(out))
+	}
+	return nil
+}
@@ -27,9 +27,11 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"os/exec"
 	"os/signal"
 	"path"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -41,6 +43,9 @@ import (
 	k8sdeviceplugin "github.com/networkservicemesh/sdk-k8s/pkg/tools/deviceplugin"
 	k8spodresources "github.com/networkservicemesh/sdk-k8s/pkg/tools/podresources"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 
 	"github.com/networkservicemesh/sdk-ovs/pkg/networkservice/chains/forwarder"
 	ovsutil "github.com/networkservicemesh/sdk-ovs/pkg/tools/utils"
@@ -68,6 +73,7 @@ import (
 
 	"github.com/networkservicemesh/cmd-forwarder-ovs/internal/l2resourcecfg"
 	"github.com/networkservicemesh/cmd-forwarder-ovs/internal/ovsinit"
+	"github.com/networkservicemesh/cmd-forwarder-ovs/pkg/tools/switchdev"
 )
 
 // Config - configuration for cmd-forwarder-ovs
@@ -83,19 +89,27 @@ type Config struct {
 	ResourcePollTimeout    time.Duration     `default:"30s" desc:"device plugin polling timeout" split_words:"true"`
 	DevicePluginPath       string            `default:"/var/lib/kubelet/device-plugins/" desc:"path to the device plugin directory" split_words:"true"`
 	PodResourcesPath       string            `default:"/var/lib/kubelet/pod-resources/" desc:"path to the pod resources directory" split_words:"true"`
+	SRIOVEnabled           bool              `default:"false" desc:"enables the SR-IOV forwarder mode; when false, the kernel forwarder is used and no SR-IOV/device-plugin setup is attempted" split_words:"true"`
 	SRIOVConfigFile        string            `default:"pci.config" desc:"PCI resources config path" split_words:"true"`
+	ForwardingMode         string            `default:"legacy" desc:"SR-IOV forwarding mode: \"legacy\" (VFIO passthrough) or \"switchdev\" (VF representors plugged into the OvS bridge with hardware offload)" split_words:"true"`
 	L2ResourceSelectorFile string            `default:"" desc:"config file for resource to label matching" split_words:"true"`
 	PCIDevicesPath         string            `default:"/sys/bus/pci/devices" desc:"path to the PCI devices directory" split_words:"true"`
 	PCIDriversPath         string            `default:"/sys/bus/pci/drivers" desc:"path to the PCI drivers directory" split_words:"true"`
+	NetDevicesPath         string            `default:"/sys/class/net" desc:"path to the network devices directory, used to detect switchdev PFs and their VF representors" split_words:"true"`
 	CgroupPath             string            `default:"/host/sys/fs/cgroup/devices" desc:"path to the host cgroup directory" split_words:"true"`
 	VFIOPath               string            `default:"/host/dev/vfio" desc:"path to the host VFIO directory" split_words:"true"`
+	OVSDBEndpoint          string            `desc:"remote OVSDB endpoint (e.g. unix:/host/var/run/openvswitch/db.sock or tcp:10.0.0.5:6640); when set, the forwarder does not start its own ovsdb-server/ovs-vswitchd and instead programs this endpoint" split_words:"true"`
+	StartupRetryTimeout    time.Duration     `default:"2m" desc:"overall budget to wait for downstream dependencies (ovs, spire agent, nsmgr) to become ready during startup" split_words:"true"`
+	StartupRetryInterval   time.Duration     `default:"5s" desc:"how often to retry a startup phase while waiting for a downstream dependency" split_words:"true"`
 	LogLevel               string            `default:"INFO" desc:"Log level" split_words:"true"`
 	OpenTelemetryEndpoint  string            `default:"otel-collector.observability.svc.cluster.local:4317" desc:"OpenTelemetry Collector Endpoint"`
 }
 
-// supervisor starting ovsdb-server and ovs-vswitchd,
-// each with 5 seconds starting timeout and 3 retries
-const startOvsTimeout = 30
+// ForwardingMode values accepted for Config.ForwardingMode.
+const (
+	forwardingModeLegacy    = "legacy"
+	forwardingModeSwitchdev = "switchdev"
+)
 
 func main() {
 	// ********************************************************************************
@@ -105,12 +119,16 @@ func main() {
 		context.Background(),
 		os.Interrupt,
 		// More Linux signals here
-		syscall.SIGHUP,
 		syscall.SIGTERM,
 		syscall.SIGQUIT,
 	)
 	defer cancel()
 
+	// SIGHUP triggers a config reload instead of shutting the forwarder down.
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	defer signal.Stop(hupCh)
+
 	setupLogger(ctx)
 
 	starttime := time.Now()
@@ -153,11 +171,25 @@ func main() {
 	log.FromContext(ctx).Infof("executing phase 2: ensure ovs is running (time since start: %s)", time.Since(starttime))
 	// ********************************************************************************
 	now = time.Now()
-	if !ovsinit.IsOvsRunning() {
+	if config.OVSDBEndpoint != "" {
+		// a node-level OvS managed by another daemon is in use; don't supervise our own,
+		// just make sure it is reachable before we start programming it.
+		if socketPath := strings.TrimPrefix(config.OVSDBEndpoint, "unix:"); socketPath != config.OVSDBEndpoint {
+			if _, err := os.Stat(socketPath); err != nil {
+				log.FromContext(ctx).Fatalf("ovsdb socket %s is not accessible: %+v", socketPath, err)
+			}
+		}
+		if err := withStartupRetry(ctx, config, "phase 2: ensure ovs is running", func(ctx context.Context) error {
+			return ovsinit.CheckEndpointReady(ctx, config.OVSDBEndpoint)
+		}); err != nil {
+			log.FromContext(ctx).Fatalf("remote ovsdb endpoint %s is not reachable: %+v", config.OVSDBEndpoint, err)
+		}
+		log.FromContext(ctx).Infof("remote ovs at %s is being used", config.OVSDBEndpoint)
+	} else if !ovsinit.IsOvsRunning() {
 		// start ovs by supervisord
 		ovsErrCh := ovsinit.StartSupervisord(ctx)
 		exitOnErrCh(ctx, cancel, ovsErrCh)
-		if err := ovsinit.WaitForOvs(ctx, startOvsTimeout); err != nil {
+		if err := withStartupRetry(ctx, config, "phase 2: ensure ovs is running", ovsinit.CheckReady); err != nil {
 			log.FromContext(ctx).Fatal(err)
 		}
 		log.FromContext(ctx).Info("local ovs is being used")
@@ -170,15 +202,25 @@ func main() {
 	log.FromContext(ctx).Infof("executing phase 3: retrieving svid, check spire agent logs if this is the last line you see (time since start: %s)", time.Since(starttime))
 	// ********************************************************************************
 	now = time.Now()
-	source, err := workloadapi.NewX509Source(ctx)
-	if err != nil {
-		logrus.Fatalf("error getting x509 source: %+v", err)
-	}
-	svid, err := source.GetX509SVID()
+	var source *workloadapi.X509Source
+	err := withStartupRetry(ctx, config, "phase 3: retrieving svid", func(ctx context.Context) error {
+		newSource, svidErr := workloadapi.NewX509Source(ctx)
+		if svidErr != nil {
+			return svidErr
+		}
+		svid, svidErr := newSource.GetX509SVID()
+		if svidErr != nil {
+			// Don't leak the source's watcher goroutine and connection across retries.
+			_ = newSource.Close()
+			return svidErr
+		}
+		source = newSource
+		logrus.Infof("SVID: %q", svid.ID)
+		return nil
+	})
 	if err != nil {
 		logrus.Fatalf("error getting x509 svid: %+v", err)
 	}
-	logrus.Infof("SVID: %q", svid.ID)
 	log.FromContext(ctx).WithField("duration", time.Since(now)).Info("completed phase 3: retrieving svid")
 
 	tlsClientConfig := tlsconfig.MTLSClientConfig(source, source, tlsconfig.AuthorizeAny())
@@ -189,7 +231,10 @@ func main() {
 	// ********************************************************************************
 	log.FromContext(ctx).Infof("executing phase 4: create ovsxconnect network service endpoint (time since start: %s)", time.Since(starttime))
 	// ********************************************************************************
-	xConnectEndpoint, err := createInterposeEndpoint(ctx, config, tlsClientConfig, source)
+	l2Store := l2resourcecfg.NewStore(getL2ConnectionPointMap(ctx, config))
+	go watchL2ConfigReload(ctx, config, l2Store, hupCh)
+
+	xConnectEndpoint, err := createInterposeEndpoint(ctx, config, tlsClientConfig, source, l2Store)
 	if err != nil {
 		logrus.Fatalf("error configuring forwarder endpoint: %+v", err)
 	}
@@ -213,7 +258,13 @@ func main() {
 	// ********************************************************************************
 	log.FromContext(ctx).Infof("executing phase 6: register %s with the registry (time since start: %s)", config.NSName, time.Since(starttime))
 	// ********************************************************************************
-	err = registerEndpoint(ctx, config, tlsClientConfig, listenOn)
+	err = withStartupRetry(ctx, config, "phase 6: register with the registry", func(ctx context.Context) error {
+		// bound each dial attempt so grpc.WithBlock()+WaitForReady(true) can't hang past
+		// a single retry interval if the NSMgr at ConnectTo isn't reachable yet.
+		attemptCtx, cancelAttempt := context.WithTimeout(ctx, config.StartupRetryInterval)
+		defer cancelAttempt()
+		return registerEndpoint(attemptCtx, config, tlsClientConfig, listenOn)
+	})
 	if err != nil {
 		log.FromContext(ctx).Fatalf("failed to connect to registry: %+v", err)
 	}
@@ -256,16 +307,24 @@ func logPhases(ctx context.Context) {
 }
 
 func getL2ConnectionPointMap(ctx context.Context, cfg *Config) map[string]*ovsutil.L2ConnectionPoint {
+	l2C, err := parseL2ConnectionPointMap(ctx, cfg)
+	if err != nil {
+		log.FromContext(ctx).Fatalf("failed to get device selector configuration file: %+v", err)
+	}
+	return l2C
+}
+
+func parseL2ConnectionPointMap(ctx context.Context, cfg *Config) (map[string]*ovsutil.L2ConnectionPoint, error) {
 	if cfg.L2ResourceSelectorFile == "" {
-		return nil
+		return nil, nil
 	}
 	resource2LabSel, err := l2resourcecfg.ReadConfig(ctx, cfg.L2ResourceSelectorFile)
 	if err != nil {
-		log.FromContext(ctx).Fatalf("failed to get device selector configuration file: %+v", err)
+		return nil, err
 	}
 	if len(resource2LabSel.Interfaces) == 0 && len(resource2LabSel.Bridges) == 0 {
 		log.FromContext(ctx).Warn("skipping matching labels to device names: empty interface and bridge list")
-		return nil
+		return nil, nil
 	}
 	l2C := make(map[string]*ovsutil.L2ConnectionPoint)
 	for _, device := range resource2LabSel.Interfaces {
@@ -287,7 +346,66 @@ func getL2ConnectionPointMap(ctx context.Context, cfg *Config) map[string]*ovsut
 			}
 		}
 	}
-	return l2C
+	return l2C, nil
+}
+
+// watchL2ConfigReload reloads cfg.L2ResourceSelectorFile into l2Store each time hupCh
+// fires, until ctx is done. Reloads that would remove an egress currently in use by a
+// live connection are rejected; the previous mapping is kept and the failure is logged
+// and recorded via the l2_config_reload_total OpenTelemetry counter.
+func watchL2ConfigReload(ctx context.Context, cfg *Config, l2Store *l2resourcecfg.Store, hupCh <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hupCh:
+			reloadL2Config(ctx, cfg, l2Store)
+		}
+	}
+}
+
+func reloadL2Config(ctx context.Context, cfg *Config, l2Store *l2resourcecfg.Store) {
+	l2C, err := parseL2ConnectionPointMap(ctx, cfg)
+	if err != nil {
+		log.FromContext(ctx).Errorf("l2 config reload: failed to parse %s: %+v", cfg.L2ResourceSelectorFile, err)
+		recordL2ReloadResult(ctx, false)
+		return
+	}
+	if err = l2Store.Reload(l2C); err != nil {
+		log.FromContext(ctx).Errorf("l2 config reload: %+v", err)
+		recordL2ReloadResult(ctx, false)
+		return
+	}
+	log.FromContext(ctx).Infof("l2 config reload: applied %s", cfg.L2ResourceSelectorFile)
+	recordL2ReloadResult(ctx, true)
+}
+
+var (
+	l2ReloadCounterOnce sync.Once
+	l2ReloadCounter     metric.Int64Counter
+)
+
+// recordL2ReloadResult increments the l2_config_reload_total OpenTelemetry counter so
+// operators can alarm on repeated bad L2ResourceSelectorFile pushes.
+func recordL2ReloadResult(ctx context.Context, success bool) {
+	l2ReloadCounterOnce.Do(func() {
+		var err error
+		l2ReloadCounter, err = otel.Meter("forwarder-ovs").Int64Counter(
+			"l2_config_reload_total",
+			metric.WithDescription("Count of L2ResourceSelectorFile reload attempts by result"),
+		)
+		if err != nil {
+			log.FromContext(ctx).Errorf("failed to create l2_config_reload_total counter: %+v", err)
+		}
+	})
+	if l2ReloadCounter == nil {
+		return
+	}
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	l2ReloadCounter.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
 }
 
 func parseTunnelIPCIDR(tunnelIPStr string) (net.IP, error) {
@@ -304,22 +422,56 @@ func parseTunnelIPCIDR(tunnelIPStr string) (net.IP, error) {
 	return egressTunnelIP, err
 }
 
-func createInterposeEndpoint(ctx context.Context, config *Config, tlsClientConfig *tls.Config, source x509svid.Source) (xConnectEndpoint endpoint.Endpoint, err error) {
+// withStartupRetry repeatedly calls fn until it succeeds, cfg.StartupRetryTimeout elapses,
+// or ctx is done, sleeping cfg.StartupRetryInterval between attempts. phaseName identifies
+// the phase in logs and as the name of the OpenTelemetry span recording the attempt count.
+// It only gives up once the overall budget is exhausted, making startup resilient to
+// typical Kubernetes ordering races (SPIRE agent, NSMgr, or ovsdb-server not yet up).
+func withStartupRetry(ctx context.Context, cfg *Config, phaseName string, fn func(context.Context) error) error {
+	spanCtx, span := otel.Tracer("forwarder-ovs").Start(ctx, phaseName)
+	defer span.End()
+
+	start := time.Now()
+	deadline := start.Add(cfg.StartupRetryTimeout)
+	for attempt := 1; ; attempt++ {
+		err := fn(spanCtx)
+		if err == nil {
+			span.SetAttributes(attribute.Int("attempts", attempt))
+			log.FromContext(ctx).Infof("%s ready after %d attempt(s)", phaseName, attempt)
+			return nil
+		}
+
+		elapsed := time.Since(start)
+		log.FromContext(ctx).Warnf("%s attempt %d failed (elapsed %s/%s): %+v", phaseName, attempt, elapsed, cfg.StartupRetryTimeout, err)
+		if time.Now().After(deadline) {
+			span.SetAttributes(attribute.Int("attempts", attempt))
+			return errors.Wrapf(err, "%s did not become ready within %s", phaseName, cfg.StartupRetryTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(cfg.StartupRetryInterval):
+		}
+	}
+}
+
+func createInterposeEndpoint(ctx context.Context, config *Config, tlsClientConfig *tls.Config, source x509svid.Source,
+	l2Store *l2resourcecfg.Store) (xConnectEndpoint endpoint.Endpoint, err error) {
 	egressTunnelIP, err := parseTunnelIPCIDR(config.TunnelIP)
 	if err != nil {
 		return
 	}
-	l2cMap := getL2ConnectionPointMap(ctx, config)
-	if isSriovConfig(config.SRIOVConfigFile) {
-		xConnectEndpoint, err = createSriovInterposeEndpoint(ctx, config, tlsClientConfig, source, egressTunnelIP, l2cMap)
+	if config.SRIOVEnabled {
+		xConnectEndpoint, err = createSriovInterposeEndpoint(ctx, config, tlsClientConfig, source, egressTunnelIP, l2Store)
 	} else {
-		xConnectEndpoint, err = createKernelInterposeEndpoint(ctx, config, tlsClientConfig, source, egressTunnelIP, l2cMap)
+		xConnectEndpoint, err = createKernelInterposeEndpoint(ctx, config, tlsClientConfig, source, egressTunnelIP, l2Store)
 	}
 	return
 }
 
 func createKernelInterposeEndpoint(ctx context.Context, config *Config, tlsConfig *tls.Config, source x509svid.Source,
-	egressTunnelIP net.IP, l2cMap map[string]*ovsutil.L2ConnectionPoint) (endpoint.Endpoint, error) {
+	egressTunnelIP net.IP, l2Store *l2resourcecfg.Store) (endpoint.Endpoint, error) {
 	return forwarder.NewKernelServer(
 		ctx,
 		config.Name,
@@ -327,9 +479,11 @@ func createKernelInterposeEndpoint(ctx context.Context, config *Config, tlsConfi
 		spiffejwt.TokenGeneratorFunc(source, config.MaxTokenLifetime),
 		&config.ConnectTo,
 		config.BridgeName,
+		config.OVSDBEndpoint,
 		egressTunnelIP,
 		config.DialTimeout,
-		l2cMap,
+		l2Store.Get,
+		l2Store.Release,
 		grpc.WithBlock(),
 		grpc.WithTransportCredentials(
 			grpcfd.TransportCredentials(credentials.NewTLS(tlsConfig))),
@@ -342,7 +496,15 @@ func createKernelInterposeEndpoint(ctx context.Context, config *Config, tlsConfi
 }
 
 func createSriovInterposeEndpoint(ctx context.Context, config *Config, tlsConfig *tls.Config, source x509svid.Source,
-	egressTunnelIP net.IP, l2cMap map[string]*ovsutil.L2ConnectionPoint) (endpoint.Endpoint, error) {
+	egressTunnelIP net.IP, l2Store *l2resourcecfg.Store) (endpoint.Endpoint, error) {
+	if config.ForwardingMode != forwardingModeLegacy && config.ForwardingMode != forwardingModeSwitchdev {
+		return nil, errors.Errorf("invalid NSM_FORWARDING_MODE %q: must be %q or %q", config.ForwardingMode, forwardingModeLegacy, forwardingModeSwitchdev)
+	}
+
+	if _, err := os.Stat(config.SRIOVConfigFile); err != nil {
+		return nil, errors.Wrapf(err, "NSM_SRIOV_ENABLED is set but SR-IOV config file %q is not accessible", config.SRIOVConfigFile)
+	}
+
 	sriovConfig, err := sriovconfig.ReadConfig(ctx, config.SRIOVConfigFile)
 	if err != nil {
 		return nil, err
@@ -373,6 +535,16 @@ func createSriovInterposeEndpoint(ctx context.Context, config *Config, tlsConfig
 		return nil, err
 	}
 
+	var switchdevPool *switchdev.Pool
+	if config.ForwardingMode == forwardingModeSwitchdev {
+		if switchdevPool, err = switchdev.NewPool(config.NetDevicesPath, sriovConfig); err != nil {
+			return nil, errors.Wrap(err, "failed to build switchdev VF representor pool")
+		}
+		if err = enableHardwareOffload(ctx, config.BridgeName, config.OVSDBEndpoint); err != nil {
+			return nil, errors.Wrap(err, "failed to enable OvS hardware offload")
+		}
+	}
+
 	return forwarder.NewSriovServer(
 		ctx,
 		config.Name,
@@ -380,12 +552,15 @@ func createSriovInterposeEndpoint(ctx context.Context, config *Config, tlsConfig
 		spiffejwt.TokenGeneratorFunc(source, config.MaxTokenLifetime),
 		&config.ConnectTo,
 		config.BridgeName,
+		config.OVSDBEndpoint,
 		egressTunnelIP,
 		pciPool,
 		resourcePool,
 		sriovConfig,
 		config.DialTimeout,
-		l2cMap,
+		l2Store.Get,
+		l2Store.Release,
+		switchdevPool,
 		grpc.WithBlock(),
 		grpc.WithTransportCredentials(
 			grpcfd.TransportCredentials(credentials.NewTLS(tlsConfig))),
@@ -397,6 +572,26 @@ func createSriovInterposeEndpoint(ctx context.Context, config *Config, tlsConfig
 	)
 }
 
+// enableHardwareOffload turns on OvS datapath hardware offload so that flows involving
+// VF representor ports get programmed into NIC hardware via tc. The setting applies to
+// the whole Open_vSwitch instance, not only to bridgeName, which is used for logging.
+// When ovsdbEndpoint is set, the ovs-vsctl invocation targets it via --db instead of
+// the local socket, same as ovsinit.CheckEndpointReady.
+func enableHardwareOffload(ctx context.Context, bridgeName, ovsdbEndpoint string) error {
+	args := []string{}
+	if ovsdbEndpoint != "" {
+		args = append(args, "--db="+ovsdbEndpoint)
+	}
+	args = append(args, "set", "Open_vSwitch", ".", "other_config:hw-offload=true")
+
+	cmd := exec.CommandContext(ctx, "ovs-vsctl", args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "ovs-vsctl set hw-offload failed: %s", string(out))
+	}
+	log.FromContext(ctx).Infof("enabled OvS hardware offload for bridge %s", bridgeName)
+	return nil
+}
+
 func exitOnErrCh(ctx context.Context, cancel context.CancelFunc, errCh <-chan error) {
 	// If we already have an error, log it and exit
 	select {
@@ -412,14 +607,6 @@ func exitOnErrCh(ctx context.Context, cancel context.CancelFunc, errCh <-chan er
 	}(ctx, errCh)
 }
 
-func isSriovConfig(confFile string) bool {
-	sriovConfig, err := os.Stat(confFile)
-	if os.IsNotExist(err) {
-		return false
-	}
-	return !sriovConfig.IsDir()
-}
-
 func registerGRPCServer(tlsServerConfig *tls.Config, xConnectEndpoint endpoint.Endpoint) *grpc.Server {
 	server := grpc.NewServer(append(
 		tracing.WithTracing(),
@@ -459,10 +646,6 @@ func registerEndpoint(ctx context.Context, cfg *Config, tlsClientConfig *tls.Con
 		NetworkServiceNames: []string{cfg.NSName},
 		Url:                 grpcutils.URLToTarget(listenOn),
 	})
-	if err != nil {
-		log.FromContext(ctx).Fatalf("failed to connect to registry: %+v", err)
-	}
-
 	return err
 }
 
@@ -0,0 +1,166 @@
+// Copyright (c) 2022 Nordix Foundation.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package switchdev provides a pool that maps SR-IOV VFs allocated in switchdev
+// eswitch mode to their VF representor netdevs, so they can be plugged into an
+// OVS bridge instead of being handed to the client netns directly.
+package switchdev
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	sriovconfig "github.com/networkservicemesh/sdk-sriov/pkg/sriov/config"
+)
+
+const (
+	devlinkModeFile  = "compat/devlink/mode"
+	switchdevModeStr = "switchdev"
+	physSwitchIDFile = "phys_switch_id"
+)
+
+// Pool maps PF PCI addresses in switchdev mode to their VF index -> representor
+// netdev name mapping, read once from sysfs at construction time.
+type Pool struct {
+	netDevicesPath string
+	representors   map[string]map[int]string
+}
+
+// NewPool builds a Pool from the PFs referenced in sriovConfig. PFs whose eswitch
+// mode is not switchdev are skipped - they stay on the legacy/VFIO forwarding path.
+func NewPool(netDevicesPath string, sriovConfig *sriovconfig.Config) (*Pool, error) {
+	p := &Pool{
+		netDevicesPath: netDevicesPath,
+		representors:   make(map[string]map[int]string),
+	}
+	for i := range sriovConfig.PCIDevices {
+		pf := &sriovConfig.PCIDevices[i]
+		if pf.PFInterfaceName == "" {
+			continue
+		}
+		isSwitchdev, err := p.isSwitchdev(pf.PFInterfaceName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to determine eswitch mode for PF %s", pf.PFInterfaceName)
+		}
+		if !isSwitchdev {
+			continue
+		}
+		reps, err := p.representorsFor(pf.PFInterfaceName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to enumerate VF representors for PF %s", pf.PFInterfaceName)
+		}
+		p.representors[pf.PCIAddress] = reps
+	}
+	return p, nil
+}
+
+// IsSwitchdev reports whether the PF at pciAddr was found to be in switchdev mode.
+func (p *Pool) IsSwitchdev(pciAddr string) bool {
+	_, ok := p.representors[pciAddr]
+	return ok
+}
+
+// Representor returns the representor netdev name for the given PF PCI address
+// and VF index.
+func (p *Pool) Representor(pciAddr string, vfIndex int) (string, error) {
+	reps, ok := p.representors[pciAddr]
+	if !ok {
+		return "", errors.Errorf("PF %s is not in switchdev mode", pciAddr)
+	}
+	rep, ok := reps[vfIndex]
+	if !ok {
+		return "", errors.Errorf("no VF representor found for PF %s VF %d", pciAddr, vfIndex)
+	}
+	return rep, nil
+}
+
+func (p *Pool) isSwitchdev(pfNetDev string) (bool, error) {
+	mode, err := ioutil.ReadFile(filepath.Join(p.netDevicesPath, pfNetDev, devlinkModeFile))
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(mode)) == switchdevModeStr, nil
+}
+
+// representorsFor walks /sys/class/net/<pf>/subsystem for every sibling netdev of
+// pfNetDev and maps the VF index encoded in its phys_port_name to the representor's
+// own netdev name. subsystem is a symlink to the whole netdev class, so it is shared
+// by every PF on the host - siblings are scoped to pfNetDev's own eswitch by matching
+// phys_switch_id, since phys_port_name alone (e.g. "pf0vf3") collides across PFs.
+func (p *Pool) representorsFor(pfNetDev string) (map[int]string, error) {
+	pfSwitchID, err := p.physSwitchID(pfNetDev)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read phys_switch_id for PF %s", pfNetDev)
+	}
+
+	subsystemPath := filepath.Join(p.netDevicesPath, pfNetDev, "subsystem")
+	siblings, err := ioutil.ReadDir(subsystemPath)
+	if err != nil {
+		return nil, err
+	}
+
+	reps := make(map[int]string)
+	for _, sibling := range siblings {
+		name := sibling.Name()
+		if name == pfNetDev {
+			continue
+		}
+		switchID, err := p.physSwitchID(name)
+		if err != nil || switchID != pfSwitchID {
+			// Not every sibling netdev belongs to this PF's eswitch; skip the ones that don't.
+			continue
+		}
+		portName, err := ioutil.ReadFile(filepath.Join(subsystemPath, name, "phys_port_name"))
+		if err != nil {
+			// Not every sibling netdev is a representor; skip the ones without one.
+			continue
+		}
+		vfIndex, ok := parseVFRepresentorPortName(strings.TrimSpace(string(portName)))
+		if !ok {
+			continue
+		}
+		reps[vfIndex] = name
+	}
+	return reps, nil
+}
+
+// physSwitchID reads the phys_switch_id of netDev, which is shared by a PF and all of
+// its own VF representors but not by netdevs belonging to a different eswitch instance.
+func (p *Pool) physSwitchID(netDev string) (string, error) {
+	id, err := ioutil.ReadFile(filepath.Join(p.netDevicesPath, netDev, physSwitchIDFile))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(id)), nil
+}
+
+// parseVFRepresentorPortName parses kernel phys_port_name strings of the form
+// "pf0vf3" into the VF index they represent.
+func parseVFRepresentorPortName(portName string) (int, bool) {
+	idx := strings.Index(portName, "vf")
+	if idx < 0 {
+		return 0, false
+	}
+	vfIndex, err := strconv.Atoi(portName[idx+len("vf"):])
+	if err != nil {
+		return 0, false
+	}
+	return vfIndex, true
+}